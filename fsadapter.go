@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileInfo adapts an *iNode to fs.FileInfo and fs.DirEntry so the tree can
+// be consumed by anything in the standard library that walks a filesystem.
+type fileInfo struct {
+	node *iNode
+}
+
+var (
+	_ fs.FileInfo = (*fileInfo)(nil)
+	_ fs.DirEntry = (*fileInfo)(nil)
+)
+
+func (fi *fileInfo) Name() string       { return fi.node.name }
+func (fi *fileInfo) Size() int64        { return int64(len(fi.node.data)) }
+func (fi *fileInfo) ModTime() time.Time { return time.Unix(fi.node.modificationTime, 0) }
+func (fi *fileInfo) IsDir() bool        { return fi.node.isDirectory }
+func (fi *fileInfo) Sys() any           { return fi.node }
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	if fi.node.isDirectory {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+func (fi *fileInfo) Type() fs.FileMode          { return fi.Mode().Type() }
+func (fi *fileInfo) Info() (fs.FileInfo, error) { return fi, nil }
+
+// File is an open handle onto an iNode. It implements io/fs.File plus the
+// io.Seeker and Readdir methods net/http needs to serve a directory tree.
+type File struct {
+	fsys   *FileSystem // filesystem it was opened from, for Sync; may be nil
+	node   *iNode
+	reader *bytes.Reader // nil for directories
+	dirPos int
+}
+
+func newFile(fsys *FileSystem, n *iNode) *File {
+	f := &File{fsys: fsys, node: n}
+	if !n.isDirectory {
+		f.reader = bytes.NewReader(n.data)
+	}
+	return f
+}
+
+func (f *File) Stat() (fs.FileInfo, error) { return &fileInfo{node: f.node}, nil }
+
+func (f *File) Read(p []byte) (int, error) {
+	if f.node.isDirectory {
+		return 0, &fs.PathError{Op: "read", Path: f.node.name, Err: fmt.Errorf("is a directory")}
+	}
+	return f.reader.Read(p)
+}
+
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	if f.node.isDirectory {
+		return 0, &fs.PathError{Op: "seek", Path: f.node.name, Err: fmt.Errorf("is a directory")}
+	}
+	return f.reader.Seek(offset, whence)
+}
+
+func (f *File) Close() error { return nil }
+
+// ReadDir implements fs.ReadDirFile.
+func (f *File) ReadDir(count int) ([]fs.DirEntry, error) {
+	return f.readdir(count)
+}
+
+// Readdir implements the directory-listing half of http.File.
+func (f *File) Readdir(count int) ([]fs.FileInfo, error) {
+	entries, err := f.readdir(count)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]fs.FileInfo, len(entries))
+	for i, e := range entries {
+		infos[i] = e.(*fileInfo)
+	}
+	return infos, nil
+}
+
+func (f *File) readdir(count int) ([]fs.DirEntry, error) {
+	if !f.node.isDirectory {
+		return nil, &fs.PathError{Op: "readdir", Path: f.node.name, Err: fmt.Errorf("not a directory")}
+	}
+
+	children := visibleChildren(f.node)
+	if count <= 0 {
+		entries := entriesFor(children[f.dirPos:])
+		f.dirPos = len(children)
+		return entries, nil
+	}
+
+	if f.dirPos >= len(children) {
+		return nil, io.EOF
+	}
+	end := min(f.dirPos+count, len(children))
+	entries := entriesFor(children[f.dirPos:end])
+	f.dirPos = end
+	return entries, nil
+}
+
+func entriesFor(nodes []*iNode) []fs.DirEntry {
+	entries := make([]fs.DirEntry, len(nodes))
+	for i, n := range nodes {
+		entries[i] = &fileInfo{node: n}
+	}
+	return entries
+}
+
+// visibleChildren returns n's non-deleted children, sorted by name so that
+// directory listings are stable.
+func visibleChildren(n *iNode) []*iNode {
+	out := make([]*iNode, 0, len(n.children))
+	for _, c := range n.children {
+		if cn, ok := c.(*iNode); ok && !cn.deleted {
+			out = append(out, cn)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+// FileSystem adapts an INode tree to the standard library's filesystem
+// interfaces, so it can be passed to http.FileServer, fs.WalkDir,
+// html/template, and anything else that takes an io/fs.FS. Its mutex
+// guards the whole tree: every tree-mutating method and every reader
+// acquires it before touching root or any of its descendants, so a
+// FileSystem can safely be shared across goroutines.
+type FileSystem struct {
+	root *iNode
+	mu   sync.RWMutex
+
+	backing      io.Writer  // set via SetBackingStore; written to by Sync/Flush
+	writeMu      sync.Mutex // serializes writes to backing across Flush's goroutines
+	throttleCh   throttle
+	throttleOnce sync.Once
+}
+
+var (
+	_ fs.FS         = (*FileSystem)(nil)
+	_ fs.StatFS     = (*FileSystem)(nil)
+	_ fs.ReadDirFS  = (*FileSystem)(nil)
+	_ fs.ReadFileFS = (*FileSystem)(nil)
+	_ fs.SubFS      = (*FileSystem)(nil)
+)
+
+// NewFileSystem wraps root for use with the standard library's filesystem
+// interfaces. root must be the *iNode returned by Root, CreateDirectory, or
+// CreateFile.
+func NewFileSystem(root INode) (*FileSystem, error) {
+	n, ok := root.(*iNode)
+	if !ok {
+		return nil, fmt.Errorf("filesystem: unsupported INode implementation %T", root)
+	}
+	return &FileSystem{root: n}, nil
+}
+
+// resolve walks name from fsys.root, enforcing fs.ValidPath and skipping
+// soft-deleted nodes as if they did not exist. The caller must hold
+// fsys.mu for reading or writing.
+func (fsys *FileSystem) resolve(op, name string) (*iNode, error) {
+	assertLocked(&fsys.mu)
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	node := fsys.root
+	if name != "." {
+		for _, part := range strings.Split(name, "/") {
+			next := findChild(node, part)
+			if next == nil {
+				return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+			}
+			node = next
+		}
+	}
+	if node.deleted {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return node, nil
+}
+
+func findChild(n *iNode, name string) *iNode {
+	for _, c := range n.children {
+		if cn, ok := c.(*iNode); ok && cn.name == name && !cn.deleted {
+			return cn
+		}
+	}
+	return nil
+}
+
+func (fsys *FileSystem) Open(name string) (fs.File, error) {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+	node, err := fsys.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return newFile(fsys, node), nil
+}
+
+func (fsys *FileSystem) Stat(name string) (fs.FileInfo, error) {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+	node, err := fsys.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{node: node}, nil
+}
+
+func (fsys *FileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+	node, err := fsys.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !node.isDirectory {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("not a directory")}
+	}
+	return entriesFor(visibleChildren(node)), nil
+}
+
+func (fsys *FileSystem) ReadFile(name string) ([]byte, error) {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+	node, err := fsys.resolve("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	if node.isDirectory {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fmt.Errorf("is a directory")}
+	}
+	out := make([]byte, len(node.data))
+	copy(out, node.data)
+	return out, nil
+}
+
+func (fsys *FileSystem) Sub(dir string) (fs.FS, error) {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+	node, err := fsys.resolve("sub", dir)
+	if err != nil {
+		return nil, err
+	}
+	if !node.isDirectory {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fmt.Errorf("not a directory")}
+	}
+	return &FileSystem{root: node}, nil
+}
+
+// HTTPFileSystem adapts fsys for use with http.FileServer and other
+// net/http helpers that expect an http.FileSystem.
+func (fsys *FileSystem) HTTPFileSystem() http.FileSystem {
+	return http.FS(fsys)
+}
+
+// AddChild adds child to parent, holding fsys's lock for the duration.
+func (fsys *FileSystem) AddChild(parent, child INode) error {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	return fsys.addChildLocked(parent, child)
+}
+
+func (fsys *FileSystem) addChildLocked(parent, child INode) error {
+	assertLocked(&fsys.mu)
+	if _, ok := parent.(*iNode); !ok {
+		return fmt.Errorf("filesystem: unsupported INode implementation %T", parent)
+	}
+	parent.AddChild(child)
+	return nil
+}
+
+// Delete removes node per Delete's --force/soft-delete rules, holding
+// fsys's lock for the duration.
+func (fsys *FileSystem) Delete(node INode, args ...string) error {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	return fsys.deleteLocked(node, args...)
+}
+
+func (fsys *FileSystem) deleteLocked(node INode, args ...string) error {
+	assertLocked(&fsys.mu)
+	n, ok := node.(*iNode)
+	if !ok {
+		return fmt.Errorf("filesystem: unsupported INode implementation %T", node)
+	}
+	return n.Delete(args...)
+}
+
+// CreateDirectory creates a new directory node under parent, holding
+// fsys's lock for the duration.
+func (fsys *FileSystem) CreateDirectory(name string, parent INode) (INode, error) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	return fsys.createDirectoryLocked(name, parent)
+}
+
+func (fsys *FileSystem) createDirectoryLocked(name string, parent INode) (INode, error) {
+	assertLocked(&fsys.mu)
+	if _, ok := parent.(*iNode); !ok {
+		return nil, fmt.Errorf("filesystem: unsupported INode implementation %T", parent)
+	}
+	return CreateDirectory(name, parent), nil
+}
+
+// CreateFile creates a new file node under parent, holding fsys's lock
+// for the duration.
+func (fsys *FileSystem) CreateFile(name string, parent INode, data []byte) (INode, error) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	return fsys.createFileLocked(name, parent, data)
+}
+
+func (fsys *FileSystem) createFileLocked(name string, parent INode, data []byte) (INode, error) {
+	assertLocked(&fsys.mu)
+	if _, ok := parent.(*iNode); !ok {
+		return nil, fmt.Errorf("filesystem: unsupported INode implementation %T", parent)
+	}
+	return CreateFile(name, parent, data), nil
+}
+
+// Find resolves path against fsys's root, holding fsys's lock for reading.
+// It skips soft-deleted nodes; use FindWithOptions to resolve them.
+func (fsys *FileSystem) Find(path string) (INode, error) {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+	return fsys.findLocked(path, FindOptions{})
+}
+
+// FindWithOptions is Find with control over whether soft-deleted nodes are
+// considered, for recovery tooling such as Undelete.
+func (fsys *FileSystem) FindWithOptions(path string, opts FindOptions) (INode, error) {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+	return fsys.findLocked(path, opts)
+}
+
+func (fsys *FileSystem) findLocked(path string, opts FindOptions) (INode, error) {
+	assertLocked(&fsys.mu)
+	return fsys.root.FindWithOptions(path, opts)
+}
+
+// Walk traverses fsys's tree, holding fsys's lock for reading. By default
+// it does not descend into soft-deleted subtrees; pass WalkIncludeDeleted
+// to visit them too.
+func (fsys *FileSystem) Walk(fn func(INode) error, mask ...WalkMask) error {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+	return fsys.walkLocked(fn, mask...)
+}
+
+func (fsys *FileSystem) walkLocked(fn func(INode) error, mask ...WalkMask) error {
+	assertLocked(&fsys.mu)
+	return fsys.root.Walk(fn, mask...)
+}
+
+// PrintInfo prints fsys's root node's information, holding fsys's lock for
+// reading.
+func (fsys *FileSystem) PrintInfo(args ...string) {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+	fsys.printInfoLocked(args...)
+}
+
+func (fsys *FileSystem) printInfoLocked(args ...string) {
+	assertLocked(&fsys.mu)
+	fsys.root.PrintInfo(args...)
+}
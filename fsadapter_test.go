@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFileSystemTestFS(t *testing.T) {
+	root := Root()
+	dir1 := CreateDirectory("dir1", root)
+	CreateFile("file1.txt", dir1, []byte("hello"))
+	CreateFile("top.txt", root, []byte("top"))
+	deleted := CreateFile("gone.txt", root, []byte("should not be seen"))
+	deleted.(*iNode).Delete()
+
+	fsys, err := NewFileSystem(root)
+	if err != nil {
+		t.Fatalf("NewFileSystem: %v", err)
+	}
+
+	if err := fstest.TestFS(fsys, "dir1/file1.txt", "top.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fsys.Stat("gone.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat(gone.txt) = %v, want fs.ErrNotExist", err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Undelete clears the deleted flag on the soft-deleted node at path,
+// making it visible to Find again. path is resolved including
+// soft-deleted nodes, so a node can be undeleted even while its parent
+// directory is still soft-deleted; the parent must be undeleted
+// separately for the node to become reachable through a normal Find.
+func (fsys *FileSystem) Undelete(path string) error {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	return fsys.undeleteLocked(path)
+}
+
+func (fsys *FileSystem) undeleteLocked(path string) error {
+	assertLocked(&fsys.mu)
+
+	resolved, err := fsys.root.FindWithOptions(path, FindOptions{IncludeDeleted: true})
+	if err != nil {
+		return err
+	}
+	n, ok := resolved.(*iNode)
+	if !ok {
+		return fmt.Errorf("filesystem: unsupported INode implementation %T", resolved)
+	}
+	if !n.deleted {
+		return nil
+	}
+
+	n.deleted = false
+	n.modificationTime = time.Now().Unix()
+	n.dirty = true
+	return nil
+}
+
+// GarbageCollect permanently removes every soft-deleted node whose
+// modificationTime is older than olderThan, detaching it from its parent
+// and recursively hard-deleting its children regardless of their own
+// deleted flag. It reports how many nodes were freed.
+func (fsys *FileSystem) GarbageCollect(olderThan time.Duration) (freed int, err error) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	return fsys.garbageCollectLocked(olderThan)
+}
+
+func (fsys *FileSystem) garbageCollectLocked(olderThan time.Duration) (int, error) {
+	assertLocked(&fsys.mu)
+
+	cutoff := time.Now().Add(-olderThan).Unix()
+	var stale []*iNode
+	err := fsys.root.Walk(func(n INode) error {
+		if cn, ok := n.(*iNode); ok && cn.deleted && cn.modificationTime <= cutoff {
+			stale = append(stale, cn)
+		}
+		return nil
+	}, WalkIncludeDeleted)
+	if err != nil {
+		return 0, err
+	}
+
+	freed := 0
+	removed := make(map[*iNode]bool)
+	var hardDelete func(n *iNode)
+	hardDelete = func(n *iNode) {
+		if removed[n] {
+			return
+		}
+		removed[n] = true
+		// Snapshot before recursing: hardDelete(cn) detaches cn from
+		// n.children (n is cn's parent), and mutating the slice while
+		// ranging over it would skip siblings.
+		kids := append([]INode(nil), n.children...)
+		for _, c := range kids {
+			if cn, ok := c.(*iNode); ok {
+				hardDelete(cn)
+			}
+		}
+		if parent, ok := n.parent.(*iNode); ok {
+			for i, child := range parent.children {
+				if cn, ok := child.(*iNode); ok && cn == n {
+					parent.children = append(parent.children[:i], parent.children[i+1:]...)
+					break
+				}
+			}
+		}
+		n.parent = nil
+		freed++
+	}
+
+	for _, n := range stale {
+		hardDelete(n)
+	}
+	return freed, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUndeleteRecoversNestedSoftDeletes(t *testing.T) {
+	root := Root()
+	dir := CreateDirectory("dir", root)
+	file := CreateFile("file.txt", dir, []byte("data"))
+
+	fsys, err := NewFileSystem(root)
+	if err != nil {
+		t.Fatalf("NewFileSystem: %v", err)
+	}
+
+	if err := file.(*iNode).Delete(); err != nil {
+		t.Fatalf("Delete(file): %v", err)
+	}
+	if err := dir.(*iNode).Delete(); err != nil {
+		t.Fatalf("Delete(dir): %v", err)
+	}
+
+	if _, err := fsys.Find("dir"); err == nil {
+		t.Fatal("Find(dir) should fail while dir is soft-deleted")
+	}
+	if _, err := fsys.FindWithOptions("dir", FindOptions{IncludeDeleted: true}); err != nil {
+		t.Fatalf("FindWithOptions(dir, IncludeDeleted): %v", err)
+	}
+	if _, err := fsys.FindWithOptions("dir/file.txt", FindOptions{IncludeDeleted: true}); err != nil {
+		t.Fatalf("FindWithOptions(dir/file.txt, IncludeDeleted): %v", err)
+	}
+
+	if err := fsys.Undelete("dir/file.txt"); err != nil {
+		t.Fatalf("Undelete(dir/file.txt): %v", err)
+	}
+	if err := fsys.Undelete("dir"); err != nil {
+		t.Fatalf("Undelete(dir): %v", err)
+	}
+
+	if _, err := fsys.Find("dir/file.txt"); err != nil {
+		t.Fatalf("Find(dir/file.txt) after undeleting both: %v", err)
+	}
+}
+
+func TestGarbageCollectCascadesAndRespectsAge(t *testing.T) {
+	root := Root()
+	dir := CreateDirectory("dir", root)
+	file := CreateFile("file.txt", dir, []byte("data"))
+	recent := CreateFile("recent.txt", root, []byte("new"))
+
+	fsys, _ := NewFileSystem(root)
+
+	if err := dir.(*iNode).Delete(); err != nil {
+		t.Fatalf("Delete(dir): %v", err)
+	}
+	_ = file
+	// Backdate dir's modificationTime so it is eligible for collection.
+	dir.(*iNode).modificationTime = time.Now().Add(-2 * time.Hour).Unix()
+
+	if err := recent.(*iNode).Delete(); err != nil {
+		t.Fatalf("Delete(recent): %v", err)
+	}
+
+	freed, err := fsys.GarbageCollect(time.Hour)
+	if err != nil {
+		t.Fatalf("GarbageCollect: %v", err)
+	}
+	if freed != 2 { // dir + its child file.txt
+		t.Fatalf("GarbageCollect freed = %d, want 2 (dir and its child)", freed)
+	}
+
+	if _, err := fsys.FindWithOptions("dir", FindOptions{IncludeDeleted: true}); err == nil {
+		t.Fatal("dir should be gone after GarbageCollect")
+	}
+	if _, err := fsys.FindWithOptions("recent.txt", FindOptions{IncludeDeleted: true}); err != nil {
+		t.Fatalf("recent.txt (too new to collect) should still exist: %v", err)
+	}
+}
+
+func TestGarbageCollectCascadesThroughMultipleChildren(t *testing.T) {
+	root := Root()
+	dir := CreateDirectory("dir", root)
+	live1 := CreateFile("live1.txt", dir, []byte("a"))
+	live2 := CreateFile("live2.txt", dir, []byte("b"))
+	live3 := CreateFile("live3.txt", dir, []byte("c"))
+	alreadyDeleted := CreateFile("already-deleted.txt", dir, []byte("d"))
+	live4 := CreateFile("live4.txt", dir, []byte("e"))
+
+	fsys, _ := NewFileSystem(root)
+
+	if err := alreadyDeleted.(*iNode).Delete(); err != nil {
+		t.Fatalf("Delete(already-deleted.txt): %v", err)
+	}
+	if err := dir.(*iNode).Delete(); err != nil {
+		t.Fatalf("Delete(dir): %v", err)
+	}
+	dir.(*iNode).modificationTime = time.Now().Add(-2 * time.Hour).Unix()
+
+	freed, err := fsys.GarbageCollect(time.Hour)
+	if err != nil {
+		t.Fatalf("GarbageCollect: %v", err)
+	}
+	if freed != 6 { // dir + its 5 children
+		t.Fatalf("GarbageCollect freed = %d, want 6 (dir and all 5 children)", freed)
+	}
+	for _, n := range []*iNode{live1.(*iNode), live2.(*iNode), live3.(*iNode), live4.(*iNode)} {
+		if len(n.children) != 0 {
+			t.Fatalf("child %q should have no children of its own", n.name)
+		}
+	}
+	if len(dir.(*iNode).children) != 0 {
+		t.Fatalf("dir.children = %d entries, want 0 after GarbageCollect", len(dir.(*iNode).children))
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// DebugLocksPanicMode, when enabled, makes assertLocked verify that the
+// "caller-must-hold-lock" helpers it guards are actually being invoked with
+// the lock held. It spawns a goroutine that races to acquire the same lock;
+// if that goroutine succeeds, the lock was free and the real caller was not
+// holding it, so assertLocked panics. This is only meant for tests that
+// hunt for missing-lock bugs — leave it off otherwise, since the probe
+// briefly contends the lock on every call.
+var DebugLocksPanicMode = false
+
+// assertLocked panics if mu is not currently held (for reading or writing)
+// by some caller, but only when DebugLocksPanicMode is enabled.
+func assertLocked(mu *sync.RWMutex) {
+	if !DebugLocksPanicMode {
+		return
+	}
+	acquired := make(chan struct{})
+	go func() {
+		mu.Lock()
+		close(acquired)
+		mu.Unlock()
+	}()
+	select {
+	case <-acquired:
+		panic("filesystem: caller-must-hold-lock helper invoked without the lock held")
+	case <-time.After(5 * time.Millisecond):
+	}
+}
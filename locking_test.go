@@ -0,0 +1,115 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestFileSystemConcurrentMutation(t *testing.T) {
+	root := Root()
+	fsys, err := NewFileSystem(root)
+	if err != nil {
+		t.Fatalf("NewFileSystem: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := fsys.CreateFile("f", root, []byte("x")); err != nil {
+				t.Errorf("CreateFile: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	count := 0
+	if err := fsys.Walk(func(n INode) error {
+		count++
+		return nil
+	}); err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	if count != 51 {
+		t.Fatalf("Walk visited %d nodes, want 51 (root + 50 files)", count)
+	}
+}
+
+func TestWalkStopsOnError(t *testing.T) {
+	root := Root()
+	CreateFile("a", root, nil)
+	CreateFile("b", root, nil)
+
+	visited := 0
+	sentinel := ErrActionNotAllowed
+	err := root.Walk(func(n INode) error {
+		visited++
+		if visited == 2 {
+			return sentinel
+		}
+		return nil
+	})
+	if err != sentinel {
+		t.Fatalf("Walk returned %v, want sentinel error", err)
+	}
+	if visited != 2 {
+		t.Fatalf("Walk visited %d nodes, want 2 (should stop after error)", visited)
+	}
+}
+
+func TestFindThroughFileNode(t *testing.T) {
+	root := Root()
+	CreateFile("file.txt", root, []byte("data"))
+
+	if _, err := root.Find("file.txt/nested"); err != ErrNotADirectory {
+		t.Fatalf("Find through file node = %v, want ErrNotADirectory", err)
+	}
+}
+
+func TestFileSystemDeleteForceHardDeletes(t *testing.T) {
+	root := Root()
+	file := CreateFile("file.txt", root, []byte("data"))
+	fsys, err := NewFileSystem(root)
+	if err != nil {
+		t.Fatalf("NewFileSystem: %v", err)
+	}
+
+	if err := fsys.Delete(file, "--force"); err != nil {
+		t.Fatalf("Delete(--force): %v", err)
+	}
+
+	if file.(*iNode).parent != nil {
+		t.Fatal("hard-deleted node should be detached from its parent")
+	}
+	if _, err := fsys.FindWithOptions("file.txt", FindOptions{IncludeDeleted: true}); err == nil {
+		t.Fatal("hard-deleted node should not be findable even with IncludeDeleted")
+	}
+}
+
+func TestAssertLockedPanicsWithoutLockHeld(t *testing.T) {
+	DebugLocksPanicMode = true
+	defer func() { DebugLocksPanicMode = false }()
+
+	t.Run("panics when unlocked", func(t *testing.T) {
+		var mu sync.RWMutex
+		defer func() {
+			if recover() == nil {
+				t.Fatal("assertLocked should have panicked: mu was not held")
+			}
+		}()
+		assertLocked(&mu)
+	})
+
+	t.Run("does not panic when held", func(t *testing.T) {
+		var mu sync.RWMutex
+		mu.Lock()
+		defer mu.Unlock()
+		defer func() {
+			if r := recover(); r != nil {
+				t.Fatalf("assertLocked panicked even though mu was held: %v", r)
+			}
+		}()
+		assertLocked(&mu)
+	})
+}
@@ -5,24 +5,47 @@ import (
 	"os"
 	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
 var (
 	// ErrActionNotAllowed is returned when an action is not allowed on the INode.
 	ErrActionNotAllowed = fmt.Errorf("action not allowed on this INode")
+	// ErrNotADirectory is returned when a path component traverses a file node.
+	ErrNotADirectory = fmt.Errorf("not a directory")
+	// ErrIsDirectory is returned when an operation that does not support
+	// directories is given one, such as renaming onto an existing directory.
+	ErrIsDirectory = fmt.Errorf("is a directory")
 )
 
 // INode is an interface that defines the methods for an INode in a filesystem-like structure.
 type INode interface {
 	AddChild(child INode)
 	PrintInfo(args ...string)
-	Walk(fn func(INode))
+	Walk(fn func(INode) error, mask ...WalkMask) error
 	Find(path string) (INode, error)
 }
 
+// WalkMask controls which nodes Walk descends into.
+type WalkMask int
+
+const (
+	// WalkIncludeDeleted makes Walk descend into soft-deleted subtrees
+	// instead of skipping them, for recovery tooling such as GarbageCollect.
+	WalkIncludeDeleted WalkMask = 1 << iota
+)
+
+// FindOptions controls how Find resolves a path.
+type FindOptions struct {
+	// IncludeDeleted makes Find resolve through and return soft-deleted
+	// nodes instead of treating them as if they did not exist.
+	IncludeDeleted bool
+}
+
 // iNode is a struct that implements the INode interface.
 type iNode struct {
+	id               uint64
 	name             string
 	parent           INode
 	children         []INode
@@ -31,11 +54,21 @@ type iNode struct {
 	isDirectory      bool
 	data             []byte
 	deleted          bool
+	dirty            bool // set on any mutation, cleared once Sync/Flush persists the node
+}
+
+// nextNodeID hands out the persistent, process-wide unique ids that
+// identify nodes in the on-disk serialization format.
+var nextNodeID uint64
+
+func newNodeID() uint64 {
+	return atomic.AddUint64(&nextNodeID, 1)
 }
 
 // Create the root INode with no parent and no children.
 func Root() INode {
 	return &iNode{
+		id:               newNodeID(),
 		name:             "/",
 		parent:           nil,
 		children:         make([]INode, 0),
@@ -44,6 +77,7 @@ func Root() INode {
 		isDirectory:      true,
 		data:             nil,
 		deleted:          false,
+		dirty:            true,
 	}
 }
 
@@ -51,6 +85,7 @@ func Root() INode {
 func (n *iNode) AddChild(child INode) {
 	if childNode, ok := child.(*iNode); ok {
 		childNode.parent = n
+		childNode.dirty = true
 		n.children = append(n.children, childNode)
 	}
 }
@@ -58,6 +93,7 @@ func (n *iNode) AddChild(child INode) {
 // CreateDirectory creates a new directory INode with the given name and parent.
 func CreateDirectory(name string, parent INode) INode {
 	n := &iNode{
+		id:               newNodeID(),
 		name:             name,
 		parent:           parent,
 		children:         make([]INode, 0),
@@ -65,6 +101,7 @@ func CreateDirectory(name string, parent INode) INode {
 		modificationTime: time.Now().Unix(),
 		isDirectory:      true,
 		data:             nil,
+		dirty:            true,
 	}
 	parent.AddChild(n)
 	return n
@@ -73,12 +110,14 @@ func CreateDirectory(name string, parent INode) INode {
 // CreateFile creates a new file INode with the given name, parent, and data.
 func CreateFile(name string, parent INode, data []byte) INode {
 	n := &iNode{
+		id:               newNodeID(),
 		name:             name,
 		parent:           parent,
 		children:         nil,
 		creationTime:     time.Now().Unix(),
 		modificationTime: time.Now().Unix(),
 		data:             data,
+		dirty:            true,
 	}
 	parent.AddChild(n)
 	return n
@@ -86,7 +125,7 @@ func CreateFile(name string, parent INode, data []byte) INode {
 
 // Delete removes the INode from its parent's children.
 func (n *iNode) Delete(args ...string) error {
-	softDelete := !slices.Contains(args, "--force") || !slices.Contains(args, "-f")
+	softDelete := !slices.Contains(args, "--force") && !slices.Contains(args, "-f")
 	if n.parent == nil {
 		return ErrActionNotAllowed
 	}
@@ -94,6 +133,7 @@ func (n *iNode) Delete(args ...string) error {
 	if softDelete {
 		n.deleted = true
 		n.modificationTime = time.Now().Unix()
+		n.dirty = true
 		return nil
 	}
 
@@ -109,12 +149,28 @@ func (n *iNode) Delete(args ...string) error {
 	return nil
 }
 
-// Walk traverses the INode and its children, applying the given function to each INode.
-func (n *iNode) Walk(fn func(INode)) {
-	fn(n)
+// Walk traverses the INode and its children, applying the given function to
+// each INode. It stops and returns the first non-nil error fn produces,
+// mirroring filepath.WalkDir. By default it does not descend into
+// soft-deleted subtrees; pass WalkIncludeDeleted to visit them too.
+func (n *iNode) Walk(fn func(INode) error, mask ...WalkMask) error {
+	var m WalkMask
+	for _, bit := range mask {
+		m |= bit
+	}
+	if n.deleted && m&WalkIncludeDeleted == 0 {
+		return nil
+	}
+
+	if err := fn(n); err != nil {
+		return err
+	}
 	for _, child := range n.children {
-		child.Walk(fn)
+		if err := child.Walk(fn, mask...); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 // PrintInfo prints the information of the INode in ls -l format.
@@ -126,34 +182,61 @@ func (n *iNode) PrintInfo(args ...string) {
 	}
 }
 
-// Find finds an INode by name in the current INode and its children.
+// Find finds an INode by name in the current INode and its children,
+// skipping soft-deleted nodes as if they did not exist. Use
+// FindWithOptions to resolve deleted nodes for recovery tooling.
 func (n *iNode) Find(path string) (INode, error) {
+	return n.FindWithOptions(path, FindOptions{})
+}
+
+// FindWithOptions is Find with control over whether soft-deleted nodes are
+// considered.
+func (n *iNode) FindWithOptions(path string, opts FindOptions) (INode, error) {
 	if path == "" || path == "/" {
+		if n.deleted && !opts.IncludeDeleted {
+			return nil, os.ErrNotExist
+		}
 		return n, nil
 	}
 
 	path = strings.Trim(path, "/")
 	parts := strings.Split(path, "/")
 
-	node := n.findRecursive(parts)
+	node, err := n.findRecursive(parts, opts)
+	if err != nil {
+		return nil, err
+	}
 	if node == nil {
 		return nil, os.ErrNotExist
 	}
 	return node, nil
 }
 
-// findRecursive is a helper function to recursively find an INode by its path.
-func (n *iNode) findRecursive(parts []string) INode {
+// findRecursive is a helper function to recursively find an INode by its
+// path. It returns ErrNotADirectory if a remaining path component would
+// have to descend through a file node.
+func (n *iNode) findRecursive(parts []string, opts FindOptions) (INode, error) {
 	if len(parts) == 0 {
-		return n
+		if n.deleted && !opts.IncludeDeleted {
+			return nil, nil
+		}
+		return n, nil
+	}
+	if !n.isDirectory {
+		return nil, ErrNotADirectory
 	}
 
 	for _, child := range n.children {
-		if childNode, ok := child.(*iNode); ok && childNode.name == parts[0] {
-			return childNode.findRecursive(parts[1:])
+		childNode, ok := child.(*iNode)
+		if !ok || childNode.name != parts[0] {
+			continue
 		}
+		if childNode.deleted && !opts.IncludeDeleted {
+			return nil, nil
+		}
+		return childNode.findRecursive(parts[1:], opts)
 	}
-	return nil
+	return nil, nil
 }
 
 func main() {
@@ -163,8 +246,9 @@ func main() {
 	dir2 := CreateDirectory("dir2", root)
 	file2 := CreateFile("file2.txt", dir2, []byte("Another file content."))
 
-	root.Walk(func(n INode) {
+	_ = root.Walk(func(n INode) error {
 		n.PrintInfo()
+		return nil
 	})
 	root.PrintInfo()
 	file1.PrintInfo()
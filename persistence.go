@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// On-disk record layout: a sequence of length-prefixed records, one per
+// node, each holding everything needed to reconstruct the tree:
+//
+//	id               uint64
+//	parentID         uint64 (0 means "no parent", i.e. this is the root)
+//	nameLen          uint32
+//	name             [nameLen]byte
+//	flags            byte (bit 0: isDirectory, bit 1: deleted)
+//	creationTime     int64
+//	modificationTime int64
+//	dataLen          uint32
+//	data             [dataLen]byte
+const (
+	recordFlagDirectory byte = 1 << 0
+	recordFlagDeleted   byte = 1 << 1
+)
+
+// defaultFlushConcurrency bounds how many nodes Flush writes out at once.
+const defaultFlushConcurrency = 4
+
+// throttle is a buffered-channel semaphore that caps the number of
+// concurrent background writers during Flush.
+type throttle chan struct{}
+
+func newThrottle(n int) throttle {
+	return make(throttle, n)
+}
+
+func (t throttle) Acquire() { t <- struct{}{} }
+func (t throttle) Release() { <-t }
+
+// throttle lazily creates and returns fsys's Flush concurrency semaphore.
+func (fsys *FileSystem) throttle() throttle {
+	fsys.throttleOnce.Do(func() {
+		fsys.throttleCh = newThrottle(defaultFlushConcurrency)
+	})
+	return fsys.throttleCh
+}
+
+// SetBackingStore points fsys at the writer Sync and Flush persist to.
+func (fsys *FileSystem) SetBackingStore(w io.Writer) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	fsys.backing = w
+}
+
+// Sync walks the tree, writes out every node with unsynced changes to
+// fsys's backing store, and clears their dirty flags.
+func (fsys *FileSystem) Sync() error {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	return fsys.syncLocked()
+}
+
+func (fsys *FileSystem) syncLocked() error {
+	assertLocked(&fsys.mu)
+	if fsys.backing == nil {
+		return fmt.Errorf("filesystem: no backing store configured, call SetBackingStore first")
+	}
+
+	// Walk with WalkIncludeDeleted: soft-deleted nodes still need their
+	// tombstone state persisted, and GarbageCollect relies on Sync having
+	// written it.
+	var dirty []*iNode
+	_ = fsys.walkLocked(func(n INode) error {
+		if cn, ok := n.(*iNode); ok && cn.dirty {
+			dirty = append(dirty, cn)
+		}
+		return nil
+	}, WalkIncludeDeleted)
+
+	fsys.writeMu.Lock()
+	defer fsys.writeMu.Unlock()
+	for _, n := range dirty {
+		if err := writeRecord(fsys.backing, n); err != nil {
+			return err
+		}
+		n.dirty = false
+	}
+	return nil
+}
+
+// Flush writes the nodes at the given paths to fsys's backing store in
+// parallel, bounded by fsys.throttle(). If shortBlocks is true each node's
+// data is written a few bytes at a time instead of in one call, to
+// exercise backing stores that only support short writes.
+func (fsys *FileSystem) Flush(names []string, shortBlocks bool) error {
+	fsys.mu.RLock()
+	nodes := make([]*iNode, 0, len(names))
+	for _, name := range names {
+		resolved, err := fsys.root.Find(name)
+		if err != nil {
+			fsys.mu.RUnlock()
+			return err
+		}
+		n, ok := resolved.(*iNode)
+		if !ok {
+			fsys.mu.RUnlock()
+			return fmt.Errorf("filesystem: unsupported INode implementation %T", resolved)
+		}
+		nodes = append(nodes, n)
+	}
+	backing := fsys.backing
+	fsys.mu.RUnlock()
+
+	if backing == nil {
+		return fmt.Errorf("filesystem: no backing store configured, call SetBackingStore first")
+	}
+
+	t := fsys.throttle()
+	errs := make([]error, len(nodes))
+	var wg sync.WaitGroup
+	for i, n := range nodes {
+		wg.Add(1)
+		t.Acquire()
+		go func(i int, n *iNode) {
+			defer wg.Done()
+			defer t.Release()
+			errs[i] = fsys.writeNode(backing, n, shortBlocks)
+		}(i, n)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	fsys.mu.Lock()
+	for _, n := range nodes {
+		n.dirty = false
+	}
+	fsys.mu.Unlock()
+	return nil
+}
+
+// writeNode serializes n to w, serializing access to w itself: the tree's
+// RWMutex only protects the in-memory tree, not the backing writer, which
+// Flush's goroutines share.
+func (fsys *FileSystem) writeNode(w io.Writer, n *iNode, shortBlocks bool) error {
+	fsys.writeMu.Lock()
+	defer fsys.writeMu.Unlock()
+
+	if !shortBlocks {
+		return writeRecord(w, n)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := writeRecord(buf, n); err != nil {
+		return err
+	}
+	const chunk = 8
+	b := buf.Bytes()
+	for len(b) > 0 {
+		end := min(chunk, len(b))
+		if _, err := w.Write(b[:end]); err != nil {
+			return err
+		}
+		b = b[end:]
+	}
+	return nil
+}
+
+func writeRecord(w io.Writer, n *iNode) error {
+	var parentID uint64
+	if pn, ok := n.parent.(*iNode); ok {
+		parentID = pn.id
+	}
+
+	var flags byte
+	if n.isDirectory {
+		flags |= recordFlagDirectory
+	}
+	if n.deleted {
+		flags |= recordFlagDeleted
+	}
+
+	buf := new(bytes.Buffer)
+	for _, v := range []any{n.id, parentID} {
+		if err := binary.Write(buf, binary.BigEndian, v); err != nil {
+			return err
+		}
+	}
+	if err := writeLengthPrefixed(buf, []byte(n.name)); err != nil {
+		return err
+	}
+	if err := buf.WriteByte(flags); err != nil {
+		return err
+	}
+	for _, v := range []any{n.creationTime, n.modificationTime} {
+		if err := binary.Write(buf, binary.BigEndian, v); err != nil {
+			return err
+		}
+	}
+	if err := writeLengthPrefixed(buf, n.data); err != nil {
+		return err
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeLengthPrefixed(w io.Writer, p []byte) error {
+	if err := binary.Write(w, binary.BigEndian, uint32(len(p))); err != nil {
+		return err
+	}
+	_, err := w.Write(p)
+	return err
+}
+
+// record is the decoded form of one on-disk node entry.
+type record struct {
+	id               uint64
+	parentID         uint64
+	name             string
+	isDirectory      bool
+	deleted          bool
+	creationTime     int64
+	modificationTime int64
+	data             []byte
+}
+
+func readRecord(r io.Reader) (*record, error) {
+	rec := &record{}
+	if err := binary.Read(r, binary.BigEndian, &rec.id); err != nil {
+		return nil, err // io.EOF here means "no more records"
+	}
+	if err := binary.Read(r, binary.BigEndian, &rec.parentID); err != nil {
+		return nil, err
+	}
+
+	name, err := readLengthPrefixed(r)
+	if err != nil {
+		return nil, err
+	}
+	rec.name = string(name)
+
+	var flags byte
+	if err := binary.Read(r, binary.BigEndian, &flags); err != nil {
+		return nil, err
+	}
+	rec.isDirectory = flags&recordFlagDirectory != 0
+	rec.deleted = flags&recordFlagDeleted != 0
+
+	if err := binary.Read(r, binary.BigEndian, &rec.creationTime); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(r, binary.BigEndian, &rec.modificationTime); err != nil {
+		return nil, err
+	}
+
+	data, err := readLengthPrefixed(r)
+	if err != nil {
+		return nil, err
+	}
+	rec.data = data
+	return rec, nil
+}
+
+func readLengthPrefixed(r io.Reader) ([]byte, error) {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// LoadFileSystem reconstructs a FileSystem from records written by Sync or
+// Flush.
+func LoadFileSystem(r io.Reader) (*FileSystem, error) {
+	nodes := make(map[uint64]*iNode)
+	parentOf := make(map[uint64]uint64)
+	var rootID uint64
+	var sawRoot bool
+
+	for {
+		rec, err := readRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("filesystem: load: %w", err)
+		}
+
+		n := &iNode{
+			id:               rec.id,
+			name:             rec.name,
+			isDirectory:      rec.isDirectory,
+			deleted:          rec.deleted,
+			creationTime:     rec.creationTime,
+			modificationTime: rec.modificationTime,
+			data:             rec.data,
+		}
+		if n.isDirectory {
+			n.children = make([]INode, 0)
+		}
+		nodes[rec.id] = n
+		parentOf[rec.id] = rec.parentID
+		if rec.parentID == 0 {
+			rootID, sawRoot = rec.id, true
+		}
+	}
+
+	if !sawRoot {
+		return nil, fmt.Errorf("filesystem: load: no root record found")
+	}
+	root, ok := nodes[rootID]
+	if !ok {
+		return nil, fmt.Errorf("filesystem: load: root record %d missing", rootID)
+	}
+
+	for id, n := range nodes {
+		if id == rootID {
+			continue
+		}
+		parent, ok := nodes[parentOf[id]]
+		if !ok {
+			return nil, fmt.Errorf("filesystem: load: node %d references missing parent %d", id, parentOf[id])
+		}
+		n.parent = parent
+		parent.children = append(parent.children, n)
+	}
+
+	return &FileSystem{root: root}, nil
+}
+
+// nodePath reconstructs n's path from fsys's root, for use with Find/Flush.
+func nodePath(n *iNode) string {
+	var parts []string
+	for cur := n; cur.parent != nil; {
+		parts = append(parts, cur.name)
+		parent, ok := cur.parent.(*iNode)
+		if !ok {
+			break
+		}
+		cur = parent
+	}
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return strings.Join(parts, "/")
+}
+
+// Sync flushes this file's data to its filesystem's backing store, like
+// fsync(2). It is a no-op if f was not opened from a FileSystem with a
+// backing store configured.
+func (f *File) Sync() error {
+	if f.fsys == nil {
+		return nil
+	}
+	f.fsys.mu.RLock()
+	backing := f.fsys.backing
+	f.fsys.mu.RUnlock()
+	if backing == nil {
+		return nil
+	}
+	return f.fsys.Flush([]string{nodePath(f.node)}, false)
+}
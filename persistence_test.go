@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSyncAndLoadRoundTrip(t *testing.T) {
+	root := Root()
+	dir1 := CreateDirectory("dir1", root)
+	CreateFile("file1.txt", dir1, []byte("hello"))
+	CreateFile("top.txt", root, []byte("top"))
+
+	fsys, err := NewFileSystem(root)
+	if err != nil {
+		t.Fatalf("NewFileSystem: %v", err)
+	}
+	var buf bytes.Buffer
+	fsys.SetBackingStore(&buf)
+
+	if err := fsys.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if buf.Len() == 0 {
+		t.Fatal("Sync wrote no bytes")
+	}
+
+	loaded, err := LoadFileSystem(&buf)
+	if err != nil {
+		t.Fatalf("LoadFileSystem: %v", err)
+	}
+
+	got, err := loaded.Find("dir1/file1.txt")
+	if err != nil {
+		t.Fatalf("Find(dir1/file1.txt) after load: %v", err)
+	}
+	if string(got.(*iNode).data) != "hello" {
+		t.Fatalf("loaded file1.txt data = %q, want %q", got.(*iNode).data, "hello")
+	}
+
+	if _, err := loaded.Find("top.txt"); err != nil {
+		t.Fatalf("Find(top.txt) after load: %v", err)
+	}
+}
+
+func TestSyncOnlyWritesDirtyNodes(t *testing.T) {
+	root := Root()
+	CreateFile("a.txt", root, []byte("a"))
+
+	fsys, _ := NewFileSystem(root)
+	var buf bytes.Buffer
+	fsys.SetBackingStore(&buf)
+
+	if err := fsys.Sync(); err != nil {
+		t.Fatalf("first Sync: %v", err)
+	}
+	firstLen := buf.Len()
+
+	if err := fsys.Sync(); err != nil {
+		t.Fatalf("second Sync: %v", err)
+	}
+	if buf.Len() != firstLen {
+		t.Fatalf("second Sync wrote %d more bytes, want 0 (nothing dirty)", buf.Len()-firstLen)
+	}
+}
+
+func TestFlushWritesNamedNodes(t *testing.T) {
+	root := Root()
+	CreateFile("a.txt", root, []byte("a"))
+	CreateFile("b.txt", root, []byte("b"))
+
+	fsys, _ := NewFileSystem(root)
+	var buf bytes.Buffer
+	fsys.SetBackingStore(&buf)
+
+	if err := fsys.Flush([]string{"a.txt", "b.txt"}, true); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	names := map[string]bool{}
+	for {
+		rec, err := readRecord(&buf)
+		if err != nil {
+			break
+		}
+		names[rec.name] = true
+	}
+	if !names["a.txt"] || !names["b.txt"] {
+		t.Fatalf("Flush records = %v, want both a.txt and b.txt", names)
+	}
+}
+
+func TestFileSyncFlushesSingleNode(t *testing.T) {
+	root := Root()
+	CreateFile("a.txt", root, []byte("a"))
+
+	fsys, _ := NewFileSystem(root)
+	var buf bytes.Buffer
+	fsys.SetBackingStore(&buf)
+
+	f, err := fsys.Open("a.txt")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+
+	if err := f.(*File).Sync(); err != nil {
+		t.Fatalf("File.Sync: %v", err)
+	}
+	if buf.Len() == 0 {
+		t.Fatal("File.Sync wrote no bytes")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// locker returns fsys's filesystem-wide lock. Rename takes it once for the
+// whole move instead of locking the source and destination parents
+// separately; with a single lock covering the entire tree there is no
+// lock-ordering problem (e.g. by pointer address) to solve.
+func (fsys *FileSystem) locker() sync.Locker {
+	return &fsys.mu
+}
+
+// Rename moves or renames the node at oldPath to newPath. It rejects
+// renaming the root, moving a node into one of its own descendants, and
+// overwriting an existing node at newPath.
+func (fsys *FileSystem) Rename(oldPath, newPath string) error {
+	fsys.locker().Lock()
+	defer fsys.locker().Unlock()
+	return fsys.renameLocked(oldPath, newPath)
+}
+
+func (fsys *FileSystem) renameLocked(oldPath, newPath string) error {
+	assertLocked(&fsys.mu)
+
+	srcAny, err := fsys.root.Find(oldPath)
+	if err != nil {
+		return err
+	}
+	src, ok := srcAny.(*iNode)
+	if !ok {
+		return fmt.Errorf("filesystem: unsupported INode implementation %T", srcAny)
+	}
+	if src == fsys.root {
+		return ErrActionNotAllowed
+	}
+	if isDescendantPath(oldPath, newPath) {
+		return fmt.Errorf("filesystem: cannot move %q into itself", oldPath)
+	}
+
+	destDir, destName := splitPath(newPath)
+	destParentAny, err := fsys.root.Find(destDir)
+	if err != nil {
+		return err
+	}
+	destParent, ok := destParentAny.(*iNode)
+	if !ok || !destParent.isDirectory {
+		return ErrNotADirectory
+	}
+
+	if existing := findChild(destParent, destName); existing != nil {
+		if existing.isDirectory {
+			return ErrIsDirectory
+		}
+		return fmt.Errorf("filesystem: destination %q already exists", newPath)
+	}
+
+	srcParent, ok := src.parent.(*iNode)
+	if !ok {
+		return fmt.Errorf("filesystem: unsupported INode implementation %T", src.parent)
+	}
+	for i, child := range srcParent.children {
+		if cn, ok := child.(*iNode); ok && cn == src {
+			srcParent.children = append(srcParent.children[:i], srcParent.children[i+1:]...)
+			break
+		}
+	}
+
+	src.name = destName
+	src.parent = destParent
+	destParent.children = append(destParent.children, src)
+	src.modificationTime = time.Now().Unix()
+	src.dirty = true
+	return nil
+}
+
+// splitPath splits a Find-style path (slash-separated, optionally
+// slash-wrapped) into its parent directory path and final component.
+func splitPath(path string) (dir, name string) {
+	path = strings.Trim(path, "/")
+	idx := strings.LastIndex(path, "/")
+	if idx < 0 {
+		return "", path
+	}
+	return path[:idx], path[idx+1:]
+}
+
+// isDescendantPath reports whether newPath is oldPath itself or nested
+// inside it, which would turn a move into a cycle.
+func isDescendantPath(oldPath, newPath string) bool {
+	oldClean := strings.Trim(oldPath, "/")
+	newClean := strings.Trim(newPath, "/")
+	return newClean == oldClean || strings.HasPrefix(newClean, oldClean+"/")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestRenameMovesAndRenames(t *testing.T) {
+	root := Root()
+	src := CreateDirectory("src", root)
+	dst := CreateDirectory("dst", root)
+	CreateFile("a.txt", src, []byte("hi"))
+
+	fsys, err := NewFileSystem(root)
+	if err != nil {
+		t.Fatalf("NewFileSystem: %v", err)
+	}
+
+	if err := fsys.Rename("src/a.txt", "dst/b.txt"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+
+	if _, err := root.Find("src/a.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Find(src/a.txt) after rename = %v, want os.ErrNotExist", err)
+	}
+	found, err := dst.Find("b.txt")
+	if err != nil {
+		t.Fatalf("Find dst/b.txt: %v", err)
+	}
+	if found.(*iNode).name != "b.txt" {
+		t.Fatalf("moved node name = %q, want b.txt", found.(*iNode).name)
+	}
+}
+
+func TestRenameRejectsRoot(t *testing.T) {
+	root := Root()
+	CreateDirectory("dir", root)
+	fsys, _ := NewFileSystem(root)
+
+	if err := fsys.Rename("", "dir/renamed-root"); err != ErrActionNotAllowed {
+		t.Fatalf("Rename(root) = %v, want ErrActionNotAllowed", err)
+	}
+}
+
+func TestRenameRejectsOverwritingDirectory(t *testing.T) {
+	root := Root()
+	CreateDirectory("occupied", root)
+	CreateFile("file.txt", root, nil)
+	fsys, _ := NewFileSystem(root)
+
+	if err := fsys.Rename("file.txt", "occupied"); err != ErrIsDirectory {
+		t.Fatalf("Rename onto directory = %v, want ErrIsDirectory", err)
+	}
+}
+
+func TestRenameRejectsCycle(t *testing.T) {
+	root := Root()
+	parent := CreateDirectory("parent", root)
+	CreateDirectory("child", parent)
+	fsys, _ := NewFileSystem(root)
+
+	if err := fsys.Rename("parent", "parent/child/parent"); err == nil {
+		t.Fatal("Rename into own descendant should fail")
+	}
+}
+
+func TestRenameConcurrentAcrossSubtrees(t *testing.T) {
+	root := Root()
+	fsys, _ := NewFileSystem(root)
+
+	const n = 20
+	dirs := make([]INode, n)
+	for i := 0; i < n; i++ {
+		dirs[i] = CreateDirectory(fmt.Sprintf("dir%d", i), root)
+		CreateFile("f.txt", dirs[i], []byte("x"))
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < n-1; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_ = fsys.Rename(fmt.Sprintf("dir%d/f.txt", i), fmt.Sprintf("dir%d/moved%d.txt", i+1, i))
+		}(i)
+	}
+	wg.Wait()
+
+	count := 0
+	if err := fsys.Walk(func(INode) error { count++; return nil }); err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	if count == 0 {
+		t.Fatal("Walk found no nodes after concurrent renames")
+	}
+}